@@ -0,0 +1,117 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/golang/glog"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	_ "k8s.io/spark-on-k8s-operator/pkg/batchscheduler/volcano"
+	crdclientset "k8s.io/spark-on-k8s-operator/pkg/client/clientset/versioned"
+	"k8s.io/spark-on-k8s-operator/pkg/controller"
+	"k8s.io/spark-on-k8s-operator/pkg/webhook"
+)
+
+var (
+	master                  = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig.")
+	kubeConfig              = flag.String("kubeConfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	controllerThreads       = flag.Int("controller-threads", 10, "Number of worker threads used by the SparkApplication controller.")
+	submissionRunnerThreads = flag.Int("submission-runner-threads", 3, "Number of worker threads used by the submission runner.")
+	enableScheduler         = flag.Bool("enable-scheduled-applications", true, "Whether to enable the ScheduledSparkApplication controller.")
+	enableMetrics           = flag.Bool("enable-metrics", true, "Whether to expose Prometheus metrics.")
+	metricsPort             = flag.String("metrics-port", "10254", "Port to serve Prometheus metrics on.")
+	metricsEndpoint         = flag.String("metrics-endpoint", "/metrics", "Endpoint to serve Prometheus metrics on.")
+	enableWebhook           = flag.Bool("enable-webhook", false, "Whether to enable the mutating admission webhook for driver and executor pods.")
+	webhookNamespace        = flag.String("webhook-namespace", "default", "The namespace the webhook's Service and Secret live in.")
+	ingressURLFormat        = flag.String("ingress-url-format", "", "Go template for the host/path of the Spark UI Ingress, e.g. '{{.AppName}}.spark.example.com'. Leave empty to not create Ingresses.")
+	ingressClassName        = flag.String("ingress-class-name", "", "The ingress class to annotate Spark UI Ingresses with.")
+)
+
+func main() {
+	flag.Parse()
+
+	config, err := buildConfig(*master, *kubeConfig)
+	if err != nil {
+		glog.Fatalf("failed to build the kubeconfig: %v", err)
+	}
+
+	kubeClient, err := clientset.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("failed to create a Kubernetes client: %v", err)
+	}
+
+	crdClient, err := crdclientset.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("failed to create a CRD client: %v", err)
+	}
+
+	extensionsClient, err := apiextensionsclient.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("failed to create an API extensions client: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+
+	if *enableMetrics {
+		controller.ServeMetrics(*metricsEndpoint, *metricsPort)
+	}
+
+	applicationController := controller.New(crdClient, kubeClient, extensionsClient, *submissionRunnerThreads, config,
+		*ingressURLFormat, *ingressClassName)
+	if err = applicationController.Start(*controllerThreads, stopCh); err != nil {
+		glog.Fatalf("failed to start the SparkApplication controller: %v", err)
+	}
+	defer applicationController.Stop()
+
+	var scheduledController *controller.ScheduledSparkApplicationController
+	if *enableScheduler {
+		scheduledController = controller.NewScheduled(crdClient, kubeClient, extensionsClient)
+		if err = scheduledController.Start(*controllerThreads, stopCh); err != nil {
+			glog.Fatalf("failed to start the ScheduledSparkApplication controller: %v", err)
+		}
+		defer scheduledController.Stop()
+	}
+
+	if *enableWebhook {
+		webhookServer := webhook.New(crdClient, kubeClient, *webhookNamespace)
+		if err = webhookServer.Start(stopCh); err != nil {
+			glog.Fatalf("failed to start the mutating admission webhook: %v", err)
+		}
+		defer webhookServer.Stop()
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGTERM, syscall.SIGINT)
+	<-signalCh
+	close(stopCh)
+}
+
+func buildConfig(master, kubeConfig string) (*rest.Config, error) {
+	if kubeConfig != "" {
+		return clientcmd.BuildConfigFromFlags(master, kubeConfig)
+	}
+	return rest.InClusterConfig()
+}
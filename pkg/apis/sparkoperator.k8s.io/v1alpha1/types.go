@@ -0,0 +1,287 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SparkApplication represents a Spark application running on and using Kubernetes as a cluster manager.
+type SparkApplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              SparkApplicationSpec   `json:"spec"`
+	Status            SparkApplicationStatus `json:"status,omitempty"`
+}
+
+// SparkApplicationList carries a list of SparkApplication objects.
+type SparkApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []SparkApplication `json:"items,omitempty"`
+}
+
+// SparkApplicationSpec describes the specification of a Spark application using Kubernetes as a cluster manager.
+type SparkApplicationSpec struct {
+	Type                SparkApplicationType `json:"type"`
+	Mode                DeployMode           `json:"mode"`
+	Image               *string              `json:"image,omitempty"`
+	MainClass           *string              `json:"mainClass,omitempty"`
+	MainApplicationFile *string              `json:"mainApplicationFile,omitempty"`
+	Arguments           []string             `json:"arguments,omitempty"`
+	SparkConf           map[string]string    `json:"sparkConf,omitempty"`
+	HadoopConf          map[string]string    `json:"hadoopConf,omitempty"`
+	Volumes             []apiv1.Volume       `json:"volumes,omitempty"`
+	Driver              DriverSpec           `json:"driver"`
+	Executor            ExecutorSpec         `json:"executor"`
+	RestartPolicy       RestartPolicy        `json:"restartPolicy,omitempty"`
+	// BatchScheduler is the name of a registered batch scheduler, e.g. "volcano", to use for gang
+	// scheduling the driver and executor pods of this application.
+	// +optional
+	BatchScheduler *string `json:"batchScheduler,omitempty"`
+	// BatchSchedulerOptions carries scheduler-specific options consumed by the selected BatchScheduler.
+	// +optional
+	BatchSchedulerOptions *BatchSchedulerConfiguration `json:"batchSchedulerOptions,omitempty"`
+}
+
+// BatchSchedulerConfiguration captures the options used by a pluggable batch scheduler to gang
+// schedule the driver and executor pods of a SparkApplication.
+type BatchSchedulerConfiguration struct {
+	// Queue is the name of the scheduling queue the application should be submitted to.
+	// +optional
+	Queue *string `json:"queue,omitempty"`
+	// PriorityClassName is the name of the PriorityClass the driver and executor pods should use.
+	// +optional
+	PriorityClassName *string `json:"priorityClassName,omitempty"`
+	// Resources is the minimum total amount of resources the scheduler should reserve for the
+	// application's gang, computed from the driver and executor requests if left unset.
+	// +optional
+	Resources apiv1.ResourceList `json:"resources,omitempty"`
+}
+
+// SparkApplicationStatus describes the current status of a Spark application.
+type SparkApplicationStatus struct {
+	SubmissionTime metav1.Time              `json:"submissionTime,omitempty"`
+	CompletionTime metav1.Time              `json:"completionTime,omitempty"`
+	DriverInfo     DriverInfo               `json:"driverInfo"`
+	AppID          string                   `json:"appId,omitempty"`
+	AppState       ApplicationState         `json:"applicationState,omitempty"`
+	ExecutorState  map[string]ExecutorState `json:"executorState,omitempty"`
+}
+
+// DriverInfo captures information about the driver of a Spark application.
+type DriverInfo struct {
+	WebUIServiceName string `json:"webUIServiceName,omitempty"`
+	WebUIPort        int32  `json:"webUIPort,omitempty"`
+	WebUIAddress     string `json:"webUIAddress,omitempty"`
+	// WebUIIngressName is the name of the Ingress exposing the Spark UI, if ingress exposure is enabled.
+	WebUIIngressName string `json:"webUIIngressName,omitempty"`
+	// WebUIIngressAddress is the externally accessible address rendered from the ingress URL template.
+	WebUIIngressAddress string `json:"webUIIngressAddress,omitempty"`
+	PodName             string `json:"podName,omitempty"`
+}
+
+// ApplicationState describes the state of a Spark application along with an optional error message.
+type ApplicationState struct {
+	State        ApplicationStateType `json:"state"`
+	ErrorMessage string               `json:"errorMessage,omitempty"`
+}
+
+// SparkPodSpec captures the common fields shared by a driver or executor pod spec.
+type SparkPodSpec struct {
+	Cores            *int32                  `json:"cores,omitempty"`
+	CoreLimit        *string                 `json:"coreLimit,omitempty"`
+	Memory           *string                 `json:"memory,omitempty"`
+	Image            *string                 `json:"image,omitempty"`
+	ConfigMaps       []NamePath              `json:"configMaps,omitempty"`
+	Secrets          []SecretInfo            `json:"secrets,omitempty"`
+	EnvVars          map[string]string       `json:"envVars,omitempty"`
+	Labels           map[string]string       `json:"labels,omitempty"`
+	Annotations      map[string]string       `json:"annotations,omitempty"`
+	Volumes          []apiv1.Volume          `json:"volumes,omitempty"`
+	VolumeMounts     []apiv1.VolumeMount     `json:"volumeMounts,omitempty"`
+	Affinity         *apiv1.Affinity         `json:"affinity,omitempty"`
+	Tolerations      []apiv1.Toleration      `json:"tolerations,omitempty"`
+	NodeSelector     map[string]string       `json:"nodeSelector,omitempty"`
+	SchedulerName    *string                 `json:"schedulerName,omitempty"`
+	HostNetwork      *bool                   `json:"hostNetwork,omitempty"`
+	Sidecars         []apiv1.Container       `json:"sidecars,omitempty"`
+	InitContainers   []apiv1.Container       `json:"initContainers,omitempty"`
+	ImagePullSecrets []string                `json:"imagePullSecrets,omitempty"`
+	EnvFrom          []apiv1.EnvFromSource   `json:"envFrom,omitempty"`
+}
+
+// DriverSpec is the specification of the driver.
+type DriverSpec struct {
+	SparkPodSpec   `json:",inline"`
+	PodName        *string `json:"podName,omitempty"`
+	ServiceAccount *string `json:"serviceAccount,omitempty"`
+}
+
+// ExecutorSpec is the specification of the executors.
+type ExecutorSpec struct {
+	SparkPodSpec `json:",inline"`
+	Instances    *int32 `json:"instances,omitempty"`
+}
+
+// NamePath is a pair of a name and a path to which the named objects should be mounted.
+type NamePath struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// SecretInfo captures information of a secret that is to be mounted into a Spark pod.
+type SecretInfo struct {
+	Name string     `json:"name"`
+	Path string     `json:"path"`
+	Type SecretType `json:"secretType"`
+}
+
+// SecretType tells the type of a secret.
+type SecretType string
+
+// SparkApplicationType describes the type of a Spark application.
+type SparkApplicationType string
+
+// Supported values of SparkApplicationType.
+const (
+	JavaApplicationType   SparkApplicationType = "Java"
+	ScalaApplicationType  SparkApplicationType = "Scala"
+	PythonApplicationType SparkApplicationType = "Python"
+	RApplicationType      SparkApplicationType = "R"
+)
+
+// DeployMode describes the deployment mode used for a Spark application.
+type DeployMode string
+
+// Supported values of DeployMode.
+const (
+	ClusterMode DeployMode = "cluster"
+	ClientMode  DeployMode = "client"
+)
+
+// RestartPolicy is the policy followed in case the SparkApplication terminates.
+type RestartPolicy string
+
+// Supported values of RestartPolicy.
+const (
+	Undefined RestartPolicy = ""
+	Never     RestartPolicy = "Never"
+	OnFailure RestartPolicy = "OnFailure"
+	Always    RestartPolicy = "Always"
+)
+
+// ApplicationStateType represents the state of a Spark application.
+type ApplicationStateType string
+
+// Valid application states.
+const (
+	NewState              ApplicationStateType = ""
+	SubmittedState        ApplicationStateType = "SUBMITTED"
+	RunningState          ApplicationStateType = "RUNNING"
+	CompletedState        ApplicationStateType = "COMPLETED"
+	FailedState           ApplicationStateType = "FAILED"
+	FailedSubmissionState ApplicationStateType = "SUBMISSION_FAILED"
+	UnknownState          ApplicationStateType = "UNKNOWN"
+)
+
+// ExecutorState represents the state of an executor.
+type ExecutorState string
+
+// Valid executor states.
+const (
+	ExecutorPendingState   ExecutorState = "PENDING"
+	ExecutorRunningState   ExecutorState = "RUNNING"
+	ExecutorCompletedState ExecutorState = "COMPLETED"
+	ExecutorFailedState    ExecutorState = "FAILED"
+)
+
+// ScheduledSparkApplication represents a scheduled, recurring SparkApplication that gets submitted
+// on a cron schedule.
+type ScheduledSparkApplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ScheduledSparkApplicationSpec   `json:"spec"`
+	Status            ScheduledSparkApplicationStatus `json:"status,omitempty"`
+}
+
+// ScheduledSparkApplicationList carries a list of ScheduledSparkApplication objects.
+type ScheduledSparkApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ScheduledSparkApplication `json:"items,omitempty"`
+}
+
+// ScheduledSparkApplicationSpec describes the specification of a scheduled Spark application.
+type ScheduledSparkApplicationSpec struct {
+	// Schedule is the cron schedule on which the application should run.
+	Schedule string `json:"schedule"`
+	// Template is the template of the SparkApplication to be run on the given schedule.
+	Template SparkApplicationSpec `json:"template"`
+	// Suspend is a flag telling the controller to suspend subsequent runs of the application if set to true.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+	// ConcurrencyPolicy is the policy governing concurrent SparkApplication runs.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// SuccessfulRunHistoryLimit is the number of past successful runs of the application to keep.
+	// +optional
+	SuccessfulRunHistoryLimit *int32 `json:"successfulRunHistoryLimit,omitempty"`
+	// FailedRunHistoryLimit is the number of past failed runs of the application to keep.
+	// +optional
+	FailedRunHistoryLimit *int32 `json:"failedRunHistoryLimit,omitempty"`
+}
+
+// ConcurrencyPolicy governs how concurrent runs of a ScheduledSparkApplication are handled.
+type ConcurrencyPolicy string
+
+// Valid concurrency policies.
+const (
+	// ConcurrencyAllow allows ScheduledSparkApplications to run concurrently.
+	ConcurrencyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyForbid forbids concurrent runs, skipping the next run if the previous one hasn't finished.
+	ConcurrencyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyReplace kills the currently running application and replaces it with a new one.
+	ConcurrencyReplace ConcurrencyPolicy = "Replace"
+)
+
+// ScheduleState is the current scheduling state of a ScheduledSparkApplication.
+type ScheduleState string
+
+// Valid schedule states.
+const (
+	FailedValidationState ScheduleState = "FailedValidation"
+	ScheduledState        ScheduleState = "Scheduled"
+)
+
+// ScheduledSparkApplicationStatus describes the current status of a ScheduledSparkApplication.
+type ScheduledSparkApplicationStatus struct {
+	// LastRun is the time when the last run of the application was scheduled.
+	LastRun metav1.Time `json:"lastRun,omitempty"`
+	// NextRun is the time when the next run of the application will be scheduled.
+	NextRun metav1.Time `json:"nextRun,omitempty"`
+	// LastRunName is the name of the SparkApplication for the most recent run of the application.
+	LastRunName string `json:"lastRunName,omitempty"`
+	// PastSuccessfulRunNames keeps the names of SparkApplications for past successful runs.
+	PastSuccessfulRunNames []string `json:"pastSuccessfulRunNames,omitempty"`
+	// PastFailedRunNames keeps the names of SparkApplications for past failed runs.
+	PastFailedRunNames []string `json:"pastFailedRunNames,omitempty"`
+	// ScheduleState is the current scheduling state of the application.
+	ScheduleState ScheduleState `json:"scheduleState,omitempty"`
+	// Reason tells why the ScheduledSparkApplication is in the particular ScheduleState.
+	Reason string `json:"reason,omitempty"`
+}
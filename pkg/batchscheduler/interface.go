@@ -0,0 +1,63 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package batchscheduler lets a SparkApplication opt into gang scheduling through a pluggable batch
+// scheduler such as Volcano or YuniKorn.
+package batchscheduler
+
+import (
+	"fmt"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+)
+
+// Interface is implemented by every pluggable batch scheduler.
+type Interface interface {
+	// Name returns the name the scheduler is registered under and that
+	// SparkApplicationSpec.BatchScheduler refers to.
+	Name() string
+	// DoBatchSchedulingOnSubmission is called before the application is handed off to spark-submit, and
+	// should reserve whatever gang scheduling resources the implementation needs, e.g. a Volcano
+	// PodGroup, and point the driver/executor pod specs at it.
+	DoBatchSchedulingOnSubmission(app *v1alpha1.SparkApplication) error
+	// CleanupOnCompletion is called once the application reaches a terminal state and should release
+	// any resources reserved by DoBatchSchedulingOnSubmission.
+	CleanupOnCompletion(app *v1alpha1.SparkApplication) error
+}
+
+// Factory creates a new Interface instance. Implementations register a Factory with Register.
+type Factory func(config interface{}) (Interface, error)
+
+var registry = make(map[string]Factory)
+
+// Register makes a batch scheduler available under the given name.
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// GetScheduler returns the batch scheduler registered under name.
+func GetScheduler(name string, config interface{}) (Interface, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("no batch scheduler registered under name %q", name)
+	}
+	return factory(config)
+}
+
+// ShouldSchedule returns whether the application opted into a batch scheduler.
+func ShouldSchedule(app *v1alpha1.SparkApplication) bool {
+	return app.Spec.BatchScheduler != nil && *app.Spec.BatchScheduler != ""
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batchscheduler
+
+import "k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+
+// NoopSchedulerName is the name of the default scheduler used when a SparkApplication does not opt
+// into gang scheduling.
+const NoopSchedulerName = "default"
+
+func init() {
+	Register(NoopSchedulerName, func(config interface{}) (Interface, error) {
+		return &noopScheduler{}, nil
+	})
+}
+
+// noopScheduler implements Interface as a no-op, leaving pods to the default Kubernetes scheduler.
+type noopScheduler struct{}
+
+func (s *noopScheduler) Name() string {
+	return NoopSchedulerName
+}
+
+func (s *noopScheduler) DoBatchSchedulingOnSubmission(app *v1alpha1.SparkApplication) error {
+	return nil
+}
+
+func (s *noopScheduler) CleanupOnCompletion(app *v1alpha1.SparkApplication) error {
+	return nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package volcano implements a batchscheduler.Interface backed by the Volcano scheduler, gang
+// scheduling a SparkApplication's driver and executors via a Volcano PodGroup.
+package volcano
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	volcanov1beta1 "volcano.sh/volcano/pkg/apis/scheduling/v1beta1"
+	volcanoclientset "volcano.sh/volcano/pkg/client/clientset/versioned"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	"k8s.io/spark-on-k8s-operator/pkg/batchscheduler"
+)
+
+// SchedulerName is the name under which this scheduler is registered and that
+// SparkApplicationSpec.BatchScheduler should be set to in order to opt into it.
+const SchedulerName = "volcano"
+
+const podGroupNameAnnotation = "scheduling.k8s.io/group-name"
+
+func init() {
+	batchscheduler.Register(SchedulerName, func(config interface{}) (batchscheduler.Interface, error) {
+		restConfig, ok := config.(*rest.Config)
+		if !ok {
+			return nil, fmt.Errorf("volcano scheduler requires a *rest.Config")
+		}
+		client, err := volcanoclientset.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a Volcano client: %v", err)
+		}
+		return &volcanoScheduler{volcanoClient: client}, nil
+	})
+}
+
+type volcanoScheduler struct {
+	volcanoClient volcanoclientset.Interface
+}
+
+func (s *volcanoScheduler) Name() string {
+	return SchedulerName
+}
+
+// DoBatchSchedulingOnSubmission creates a PodGroup sized to the driver plus all requested executors
+// and points the driver and executor pod specs at it via spark.kubernetes.{driver,executor}.scheduler.name
+// and the scheduling.k8s.io/group-name annotation.
+func (s *volcanoScheduler) DoBatchSchedulingOnSubmission(app *v1alpha1.SparkApplication) error {
+	groupName := podGroupName(app)
+
+	minResources := sumMinResources(app)
+	minMember := int32(1)
+	if app.Spec.Executor.Instances != nil {
+		minMember += *app.Spec.Executor.Instances
+	}
+
+	podGroup := &volcanov1beta1.PodGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      groupName,
+			Namespace: app.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, v1alpha1.SchemeGroupVersion.WithKind("SparkApplication")),
+			},
+		},
+		Spec: volcanov1beta1.PodGroupSpec{
+			MinMember:    minMember,
+			MinResources: &minResources,
+		},
+	}
+
+	if app.Spec.BatchSchedulerOptions != nil {
+		if app.Spec.BatchSchedulerOptions.Queue != nil {
+			podGroup.Spec.Queue = *app.Spec.BatchSchedulerOptions.Queue
+		}
+		if app.Spec.BatchSchedulerOptions.PriorityClassName != nil {
+			podGroup.Spec.PriorityClassName = *app.Spec.BatchSchedulerOptions.PriorityClassName
+		}
+	}
+
+	_, err := s.volcanoClient.SchedulingV1beta1().PodGroups(app.Namespace).Create(podGroup)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create PodGroup %s for SparkApplication %s: %v", groupName, app.Name, err)
+	}
+
+	setSchedulerNameAndGroup(&app.Spec.Driver.SparkPodSpec, groupName)
+	setSchedulerNameAndGroup(&app.Spec.Executor.SparkPodSpec, groupName)
+
+	return nil
+}
+
+func (s *volcanoScheduler) CleanupOnCompletion(app *v1alpha1.SparkApplication) error {
+	err := s.volcanoClient.SchedulingV1beta1().PodGroups(app.Namespace).Delete(podGroupName(app), nil)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete PodGroup for SparkApplication %s: %v", app.Name, err)
+	}
+	return nil
+}
+
+func podGroupName(app *v1alpha1.SparkApplication) string {
+	return fmt.Sprintf("spark-pg-%s", app.Name)
+}
+
+func setSchedulerNameAndGroup(spec *v1alpha1.SparkPodSpec, groupName string) {
+	spec.SchedulerName = stringPtr(SchedulerName)
+	if spec.Annotations == nil {
+		spec.Annotations = make(map[string]string)
+	}
+	spec.Annotations[podGroupNameAnnotation] = groupName
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// sumMinResources returns BatchSchedulerOptions.Resources as is if set; otherwise it adds up the
+// driver's and every executor's cpu/memory requests to compute the minimum total amount of resources
+// the PodGroup needs to reserve for the whole gang.
+func sumMinResources(app *v1alpha1.SparkApplication) apiv1.ResourceList {
+	if app.Spec.BatchSchedulerOptions != nil && len(app.Spec.BatchSchedulerOptions.Resources) > 0 {
+		return app.Spec.BatchSchedulerOptions.Resources
+	}
+
+	total := apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse("0"),
+		apiv1.ResourceMemory: resource.MustParse("0"),
+	}
+
+	addPodResources(total, app.Spec.Driver.SparkPodSpec, 1)
+	instances := int32(1)
+	if app.Spec.Executor.Instances != nil {
+		instances = *app.Spec.Executor.Instances
+	}
+	addPodResources(total, app.Spec.Executor.SparkPodSpec, instances)
+
+	return total
+}
+
+func addPodResources(total apiv1.ResourceList, spec v1alpha1.SparkPodSpec, count int32) {
+	if spec.Cores != nil {
+		cpu := total[apiv1.ResourceCPU]
+		for i := int32(0); i < count; i++ {
+			cpu.Add(resource.MustParse(fmt.Sprintf("%d", *spec.Cores)))
+		}
+		total[apiv1.ResourceCPU] = cpu
+	}
+	if spec.Memory != nil {
+		mem := total[apiv1.ResourceMemory]
+		for i := int32(0); i < count; i++ {
+			if quantity, err := resource.ParseQuantity(*spec.Memory); err == nil {
+				mem.Add(quantity)
+			}
+		}
+		total[apiv1.ResourceMemory] = mem
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config holds labels, annotations and other constants shared between the
+// SparkApplicationController and the mutating admission webhook.
+package config
+
+const (
+	// SparkRoleLabel is the driver/executor pod label for the role a pod plays in a Spark application.
+	SparkRoleLabel = "spark-role"
+	// SparkDriverRole is the value of SparkRoleLabel for driver pods.
+	SparkDriverRole = "driver"
+	// SparkExecutorRole is the value of SparkRoleLabel for executor pods.
+	SparkExecutorRole = "executor"
+	// SparkExecutorIDLabel is the driver/executor pod label for the executor ID.
+	SparkExecutorIDLabel = "spark-exec-id"
+	// SparkAppNameLabel is the driver/executor pod label carrying the application ID, used to select
+	// all pods belonging to a SparkApplication for cleanup.
+	SparkAppNameLabel = "spark-app-selector"
+)
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -39,18 +40,24 @@ import (
 	"k8s.io/client-go/util/workqueue"
 
 	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	"k8s.io/spark-on-k8s-operator/pkg/batchscheduler"
 	crdclientset "k8s.io/spark-on-k8s-operator/pkg/client/clientset/versioned"
 	crdinformers "k8s.io/spark-on-k8s-operator/pkg/client/informers/externalversions"
+	"k8s.io/spark-on-k8s-operator/pkg/config"
 	"k8s.io/spark-on-k8s-operator/pkg/crd"
 	"k8s.io/spark-on-k8s-operator/pkg/util"
 )
 
 const (
-	sparkRoleLabel       = "spark-role"
-	sparkDriverRole      = "driver"
-	sparkExecutorRole    = "executor"
-	sparkExecutorIDLabel = "spark-exec-id"
+	sparkRoleLabel       = config.SparkRoleLabel
+	sparkDriverRole      = config.SparkDriverRole
+	sparkExecutorRole    = config.SparkExecutorRole
+	sparkExecutorIDLabel = config.SparkExecutorIDLabel
 	maximumUpdateRetries = 3
+
+	// sparkApplicationFinalizerName is added to every SparkApplication so the controller gets a chance
+	// to clean up driver/executor pods and the UI Service before the object is actually removed.
+	sparkApplicationFinalizerName = "sparkoperator.k8s.io/finalizer"
 )
 
 // SparkApplicationController manages instances of SparkApplication.
@@ -66,14 +73,27 @@ type SparkApplicationController struct {
 	sparkPodMonitor       *sparkPodMonitor
 	appStateReportingChan <-chan appStateUpdate
 	podStateReportingChan <-chan interface{}
+	metrics               *sparkAppMetrics
+	batchSchedulerConfig  interface{}
+	ingressURLFormat      string
+	ingressClassName      string
+
+	resubmissionMutex sync.Mutex
+	resubmissionKeys  map[string]bool
 }
 
-// New creates a new SparkApplicationController.
+// New creates a new SparkApplicationController. batchSchedulerConfig is passed verbatim to whichever
+// batchscheduler.Interface a SparkApplication selects via Spec.BatchScheduler, e.g. a *rest.Config for
+// the Volcano scheduler. ingressURLFormat is a text/template string rendering the host/path of the
+// Spark UI Ingress from the application's name and namespace; leave it empty to not create Ingresses.
 func New(
 	crdClient crdclientset.Interface,
 	kubeClient clientset.Interface,
 	extensionsClient apiextensionsclient.Interface,
-	submissionRunnerWorkers int) *SparkApplicationController {
+	submissionRunnerWorkers int,
+	batchSchedulerConfig interface{},
+	ingressURLFormat string,
+	ingressClassName string) *SparkApplicationController {
 	v1alpha1.AddToScheme(scheme.Scheme)
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(glog.V(2).Infof)
@@ -82,7 +102,8 @@ func New(
 	})
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: "spark-operator"})
 
-	return newSparkApplicationController(crdClient, kubeClient, extensionsClient, recorder, submissionRunnerWorkers)
+	return newSparkApplicationController(crdClient, kubeClient, extensionsClient, recorder, submissionRunnerWorkers,
+		batchSchedulerConfig, ingressURLFormat, ingressClassName)
 }
 
 func newSparkApplicationController(
@@ -90,7 +111,11 @@ func newSparkApplicationController(
 	kubeClient clientset.Interface,
 	extensionsClient apiextensionsclient.Interface,
 	eventRecorder record.EventRecorder,
-	submissionRunnerWorkers int) *SparkApplicationController {
+	submissionRunnerWorkers int,
+	batchSchedulerConfig interface{},
+	ingressURLFormat string,
+	ingressClassName string) *SparkApplicationController {
+	RegisterMetricAndTrackRateLimiterUsage("spark-application-controller")
 	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(),
 		"spark-application-controller")
 
@@ -100,6 +125,9 @@ func newSparkApplicationController(
 	runner := newSparkSubmitRunner(submissionRunnerWorkers, appStateReportingChan)
 	sparkPodMonitor := newSparkPodMonitor(kubeClient, podStateReportingChan)
 
+	metrics := newSparkAppMetrics()
+	metrics.register()
+
 	controller := &SparkApplicationController{
 		crdClient:             crdClient,
 		kubeClient:            kubeClient,
@@ -110,6 +138,11 @@ func newSparkApplicationController(
 		sparkPodMonitor:       sparkPodMonitor,
 		appStateReportingChan: appStateReportingChan,
 		podStateReportingChan: podStateReportingChan,
+		metrics:               metrics,
+		batchSchedulerConfig:  batchSchedulerConfig,
+		ingressURLFormat:      ingressURLFormat,
+		ingressClassName:      ingressClassName,
+		resubmissionKeys:      make(map[string]bool),
 	}
 
 	informerFactory := crdinformers.NewSharedInformerFactory(
@@ -120,6 +153,7 @@ func newSparkApplicationController(
 	controller.informer = informerFactory.Sparkoperator().V1alpha1().SparkApplications().Informer()
 	controller.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    controller.onAdd,
+		UpdateFunc: controller.onUpdate,
 		DeleteFunc: controller.onDelete,
 	})
 	controller.store = controller.informer.GetStore()
@@ -184,6 +218,45 @@ func (s *SparkApplicationController) onAdd(obj interface{}) {
 	s.queue.AddRateLimited(key)
 }
 
+// onUpdate is called on both spec updates and the setting of a DeletionTimestamp on delete, since
+// deletion of a SparkApplication with our finalizer present shows up as an update, not a DeleteFunc.
+func (s *SparkApplicationController) onUpdate(oldObj, newObj interface{}) {
+	oldApp := oldObj.(*v1alpha1.SparkApplication)
+	newApp := newObj.(*v1alpha1.SparkApplication)
+
+	key := getApplicationKey(newApp.Namespace, newApp.Name)
+
+	if !newApp.DeletionTimestamp.IsZero() {
+		s.queue.Add(key)
+		return
+	}
+
+	if !reflect.DeepEqual(oldApp.Spec, newApp.Spec) {
+		glog.Infof("SparkApplication %s was updated, cancelling the in-flight submission, if any, and resubmitting",
+			newApp.Name)
+		s.markForResubmission(key)
+		s.queue.AddRateLimited(key)
+	}
+}
+
+// markForResubmission records that the SparkApplication identified by key must have its in-flight
+// submission killed and be resubmitted from a clean Status the next time it's synced, instead of doing
+// that work directly on the informer's event delivery goroutine.
+func (s *SparkApplicationController) markForResubmission(key string) {
+	s.resubmissionMutex.Lock()
+	defer s.resubmissionMutex.Unlock()
+	s.resubmissionKeys[key] = true
+}
+
+// popResubmission reports whether key was marked for resubmission and clears the mark.
+func (s *SparkApplicationController) popResubmission(key string) bool {
+	s.resubmissionMutex.Lock()
+	defer s.resubmissionMutex.Unlock()
+	resubmission := s.resubmissionKeys[key]
+	delete(s.resubmissionKeys, key)
+	return resubmission
+}
+
 func (s *SparkApplicationController) onDelete(obj interface{}) {
 	app := obj.(*v1alpha1.SparkApplication)
 
@@ -236,27 +309,131 @@ func (s *SparkApplicationController) processNextItem() bool {
 func (s *SparkApplicationController) syncSparkApplication(key string) error {
 	app, err := s.getSparkApplicationFromStore(key)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
 		return err
 	}
-	s.submitApp(app, false)
+
+	if !app.DeletionTimestamp.IsZero() {
+		return s.cleanUpAndRemoveFinalizer(app)
+	}
+
+	resubmission := s.popResubmission(key)
+	if resubmission {
+		s.runner.kill(key)
+	}
+	s.submitApp(app, resubmission)
 	return nil
 }
 
+// cleanUpAndRemoveFinalizer kills the driver pod if it's still running, deletes the owned executor
+// pods and the Spark UI Service, then removes our finalizer so the SparkApplication can be garbage
+// collected.
+func (s *SparkApplicationController) cleanUpAndRemoveFinalizer(app *v1alpha1.SparkApplication) error {
+	if !hasFinalizer(app) {
+		return nil
+	}
+
+	if app.Status.DriverInfo.PodName != "" {
+		err := s.kubeClient.CoreV1().Pods(app.Namespace).Delete(app.Status.DriverInfo.PodName, nil)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete driver pod %s of SparkApplication %s: %v",
+				app.Status.DriverInfo.PodName, app.Name, err)
+		}
+	}
+
+	executorSelector := fmt.Sprintf("%s=%s,%s=%s", sparkRoleLabel, sparkExecutorRole, config.SparkAppNameLabel,
+		app.Status.AppID)
+	if err := s.kubeClient.CoreV1().Pods(app.Namespace).DeleteCollection(nil,
+		metav1.ListOptions{LabelSelector: executorSelector}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete executor pods of SparkApplication %s: %v", app.Name, err)
+	}
+
+	if app.Status.DriverInfo.WebUIServiceName != "" {
+		err := s.kubeClient.CoreV1().Services(app.Namespace).Delete(app.Status.DriverInfo.WebUIServiceName, nil)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete UI service %s of SparkApplication %s: %v",
+				app.Status.DriverInfo.WebUIServiceName, app.Name, err)
+		}
+	}
+
+	if app.Status.DriverInfo.WebUIIngressName != "" {
+		err := s.kubeClient.NetworkingV1().Ingresses(app.Namespace).Delete(
+			app.Status.DriverInfo.WebUIIngressName, nil)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete UI ingress %s of SparkApplication %s: %v",
+				app.Status.DriverInfo.WebUIIngressName, app.Name, err)
+		}
+	}
+
+	toUpdate := app.DeepCopy()
+	toUpdate.Finalizers = removeString(toUpdate.Finalizers, sparkApplicationFinalizerName)
+	_, err := s.crdClient.SparkoperatorV1alpha1().SparkApplications(toUpdate.Namespace).Update(toUpdate)
+	return err
+}
+
+func hasFinalizer(app *v1alpha1.SparkApplication) bool {
+	for _, f := range app.Finalizers {
+		if f == sparkApplicationFinalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(slice []string, s string) []string {
+	var result []string
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func (s *SparkApplicationController) submitApp(app *v1alpha1.SparkApplication, resubmission bool) {
 	updatedApp := s.updateSparkApplicationWithRetries(app, app.DeepCopy(), func(toUpdate *v1alpha1.SparkApplication) {
 		if resubmission {
 			// Clear the Status field if it's a resubmission.
 			toUpdate.Status = v1alpha1.SparkApplicationStatus{}
 		}
+		if !hasFinalizer(toUpdate) {
+			toUpdate.Finalizers = append(toUpdate.Finalizers, sparkApplicationFinalizerName)
+		}
 		toUpdate.Status.AppID = buildAppID(toUpdate)
 		toUpdate.Status.AppState.State = v1alpha1.NewState
-		createSparkUIService(toUpdate, s.kubeClient)
+		if err := createSparkUIService(toUpdate, s.kubeClient); err != nil {
+			glog.Errorf("failed to create the Spark UI service for SparkApplication %s: %v", toUpdate.Name, err)
+		} else if s.ingressURLFormat != "" {
+			service, err := s.kubeClient.CoreV1().Services(toUpdate.Namespace).Get(
+				toUpdate.Status.DriverInfo.WebUIServiceName, metav1.GetOptions{})
+			if err != nil {
+				glog.Errorf("failed to get the Spark UI service for SparkApplication %s: %v", toUpdate.Name, err)
+			} else if err := createSparkUIIngress(toUpdate, service, s.ingressURLFormat, s.ingressClassName,
+				s.kubeClient); err != nil {
+				glog.Errorf("failed to create the Spark UI ingress for SparkApplication %s: %v", toUpdate.Name, err)
+			}
+		}
 	})
 
 	if updatedApp == nil {
 		return
 	}
 
+	s.metrics.recordSubmission(updatedApp)
+
+	if batchscheduler.ShouldSchedule(updatedApp) {
+		scheduler, err := batchscheduler.GetScheduler(*updatedApp.Spec.BatchScheduler, s.batchSchedulerConfig)
+		if err != nil {
+			glog.Errorf("failed to get batch scheduler %s for SparkApplication %s: %v",
+				*updatedApp.Spec.BatchScheduler, updatedApp.Name, err)
+		} else if err := scheduler.DoBatchSchedulingOnSubmission(updatedApp); err != nil {
+			glog.Errorf("batch scheduler %s failed to schedule SparkApplication %s: %v", scheduler.Name(),
+				updatedApp.Name, err)
+		}
+	}
+
 	submissionCmdArgs, err := buildSubmissionCommandArgs(updatedApp)
 	if err != nil {
 		glog.Errorf(
@@ -264,7 +441,7 @@ func (s *SparkApplicationController) submitApp(app *v1alpha1.SparkApplication, r
 			updatedApp.Name,
 			err)
 	}
-	
+
 	s.runner.submit(newSubmission(submissionCmdArgs, updatedApp))
 }
 
@@ -301,6 +478,7 @@ func (s *SparkApplicationController) processSingleDriverStateUpdate(
 		return nil
 	}
 
+	oldState := app.Status.AppState.State
 	updated := s.updateSparkApplicationWithRetries(app, app.DeepCopy(), func(toUpdate *v1alpha1.SparkApplication) {
 		toUpdate.Status.DriverInfo.PodName = update.podName
 		if update.nodeName != "" {
@@ -319,7 +497,22 @@ func (s *SparkApplicationController) processSingleDriverStateUpdate(
 		}
 	})
 
+	if updated != nil {
+		s.metrics.recordAppStateTransition(updated, oldState, updated.Status.AppState.State)
+	}
+
 	if updated != nil && isAppTerminated(updated.Status.AppState.State) {
+		if batchscheduler.ShouldSchedule(updated) {
+			scheduler, err := batchscheduler.GetScheduler(*updated.Spec.BatchScheduler, s.batchSchedulerConfig)
+			if err != nil {
+				glog.Errorf("failed to get batch scheduler %s for SparkApplication %s: %v",
+					*updated.Spec.BatchScheduler, updated.Name, err)
+			} else if err := scheduler.CleanupOnCompletion(updated); err != nil {
+				glog.Errorf("batch scheduler %s failed to clean up for SparkApplication %s: %v",
+					scheduler.Name(), updated.Name, err)
+			}
+		}
+
 		s.recorder.Eventf(
 			updated,
 			apiv1.EventTypeNormal,
@@ -347,6 +540,7 @@ func (s *SparkApplicationController) processSingleAppStateUpdate(update appState
 		return
 	}
 
+	oldState := app.Status.AppState.State
 	updated := s.updateSparkApplicationWithRetries(app, app.DeepCopy(), func(toUpdate *v1alpha1.SparkApplication) {
 		toUpdate.Status.AppState.State = update.state
 		toUpdate.Status.AppState.ErrorMessage = update.errorMessage
@@ -355,6 +549,10 @@ func (s *SparkApplicationController) processSingleAppStateUpdate(update appState
 		}
 	})
 
+	if updated != nil {
+		s.metrics.recordAppStateTransition(updated, oldState, updated.Status.AppState.State)
+	}
+
 	if updated != nil && updated.Status.AppState.State == v1alpha1.FailedSubmissionState {
 		s.recorder.Eventf(
 			updated,
@@ -382,7 +580,8 @@ func (s *SparkApplicationController) processSingleExecutorStateUpdate(update *ex
 		return
 	}
 
-	s.updateSparkApplicationWithRetries(app, app.DeepCopy(), func(toUpdate *v1alpha1.SparkApplication) {
+	oldState := app.Status.ExecutorState[update.podName]
+	updated := s.updateSparkApplicationWithRetries(app, app.DeepCopy(), func(toUpdate *v1alpha1.SparkApplication) {
 		if toUpdate.Status.ExecutorState == nil {
 			toUpdate.Status.ExecutorState = make(map[string]v1alpha1.ExecutorState)
 		}
@@ -390,6 +589,12 @@ func (s *SparkApplicationController) processSingleExecutorStateUpdate(update *ex
 			toUpdate.Status.ExecutorState[update.podName] = update.state
 		}
 	})
+
+	// A pending state is never persisted to Status.ExecutorState, so recording a transition to it here
+	// would make the metric diverge from the persisted state.
+	if updated != nil && update.state != v1alpha1.ExecutorPendingState {
+		s.metrics.recordExecutorStateTransition(updated, oldState, update.state)
+	}
 }
 
 func (s *SparkApplicationController) updateSparkApplicationWithRetries(
@@ -488,6 +693,7 @@ func (s *SparkApplicationController) handleRestart(app *v1alpha1.SparkApplicatio
 			"Re-submitting SparkApplication: %s",
 			app.Name)
 
+		s.metrics.recordRestart(app)
 		s.submitApp(app, true)
 	}
 }
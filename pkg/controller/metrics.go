@@ -0,0 +1,314 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+)
+
+const metricsNamespace = "spark_operator"
+
+// sparkAppMetrics holds the Prometheus collectors tracking SparkApplication lifecycle and submission
+// behavior. A single instance is shared by all SparkApplicationController goroutines.
+type sparkAppMetrics struct {
+	appCount                *prometheus.CounterVec
+	appSubmitCount          *prometheus.CounterVec
+	appRunningCount         *prometheus.GaugeVec
+	appSuccessCount         *prometheus.CounterVec
+	appFailureCount         *prometheus.CounterVec
+	appSubmissionDuration   *prometheus.HistogramVec
+	appRunDuration          *prometheus.HistogramVec
+	appExecutorCount        *prometheus.GaugeVec
+	appExecutorFailureCount *prometheus.CounterVec
+	appRestartCount         *prometheus.CounterVec
+}
+
+func newSparkAppMetrics() *sparkAppMetrics {
+	labels := []string{"namespace"}
+
+	return &sparkAppMetrics{
+		appCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_count",
+			Help:      "Total number of SparkApplications submitted",
+		}, labels),
+		appSubmitCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_submit_count",
+			Help:      "Total number of SparkApplication submission attempts",
+		}, labels),
+		appRunningCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_running_count",
+			Help:      "Number of currently running SparkApplications",
+		}, labels),
+		appSuccessCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_success_count",
+			Help:      "Total number of SparkApplications that completed successfully",
+		}, labels),
+		appFailureCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_failure_count",
+			Help:      "Total number of SparkApplications that failed",
+		}, labels),
+		appSubmissionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_submission_duration_seconds",
+			Help:      "Time spent submitting a SparkApplication to the cluster",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		appRunDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_run_duration_seconds",
+			Help:      "Time a SparkApplication ran for, from submission to a terminal state",
+			Buckets:   prometheus.ExponentialBuckets(30, 2, 10),
+		}, labels),
+		appExecutorCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_executor_count",
+			Help:      "Number of running executors per SparkApplication",
+		}, []string{"namespace", "app_name"}),
+		appExecutorFailureCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_executor_failure_count",
+			Help:      "Total number of failed executors",
+		}, []string{"namespace", "app_name"}),
+		appRestartCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spark_app_restart_count",
+			Help:      "Total number of times SparkApplications were restarted",
+		}, labels),
+	}
+}
+
+func (m *sparkAppMetrics) register() {
+	prometheus.MustRegister(
+		m.appCount,
+		m.appSubmitCount,
+		m.appRunningCount,
+		m.appSuccessCount,
+		m.appFailureCount,
+		m.appSubmissionDuration,
+		m.appRunDuration,
+		m.appExecutorCount,
+		m.appExecutorFailureCount,
+		m.appRestartCount,
+	)
+}
+
+func (m *sparkAppMetrics) recordSubmission(app *v1alpha1.SparkApplication) {
+	m.appCount.WithLabelValues(app.Namespace).Inc()
+	m.appSubmitCount.WithLabelValues(app.Namespace).Inc()
+}
+
+func (m *sparkAppMetrics) recordAppStateTransition(app *v1alpha1.SparkApplication,
+	oldState, newState v1alpha1.ApplicationStateType) {
+	if oldState == newState {
+		return
+	}
+
+	if newState == v1alpha1.RunningState {
+		m.appRunningCount.WithLabelValues(app.Namespace).Inc()
+	} else if oldState == v1alpha1.RunningState {
+		m.appRunningCount.WithLabelValues(app.Namespace).Dec()
+	}
+
+	switch newState {
+	case v1alpha1.CompletedState:
+		m.appSuccessCount.WithLabelValues(app.Namespace).Inc()
+		m.observeRunDuration(app)
+	case v1alpha1.FailedState, v1alpha1.FailedSubmissionState:
+		m.appFailureCount.WithLabelValues(app.Namespace).Inc()
+		m.observeRunDuration(app)
+	}
+}
+
+func (m *sparkAppMetrics) observeRunDuration(app *v1alpha1.SparkApplication) {
+	if app.Status.SubmissionTime.IsZero() || app.Status.CompletionTime.IsZero() {
+		return
+	}
+	duration := app.Status.CompletionTime.Sub(app.Status.SubmissionTime.Time)
+	m.appRunDuration.WithLabelValues(app.Namespace).Observe(duration.Seconds())
+}
+
+func (m *sparkAppMetrics) recordExecutorStateTransition(app *v1alpha1.SparkApplication,
+	oldState, newState v1alpha1.ExecutorState) {
+	if oldState == newState {
+		return
+	}
+
+	if newState == v1alpha1.ExecutorRunningState {
+		m.appExecutorCount.WithLabelValues(app.Namespace, app.Name).Inc()
+	} else if oldState == v1alpha1.ExecutorRunningState {
+		m.appExecutorCount.WithLabelValues(app.Namespace, app.Name).Dec()
+	}
+
+	if newState == v1alpha1.ExecutorFailedState {
+		m.appExecutorFailureCount.WithLabelValues(app.Namespace, app.Name).Inc()
+	}
+}
+
+func (m *sparkAppMetrics) recordRestart(app *v1alpha1.SparkApplication) {
+	m.appRestartCount.WithLabelValues(app.Namespace).Inc()
+}
+
+// kubeClientRateLimiterLatency tracks how long requests to the API server spend waiting on the kube
+// client's client-side rate limiter, i.e. how much the operator is throttling itself.
+var kubeClientRateLimiterLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metricsNamespace,
+	Subsystem: "kube_client",
+	Name:      "rate_limiter_latency_seconds",
+	Help:      "Time spent waiting on the kube client's rate limiter before a request to the API server",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"verb"})
+
+var registerClientGoMetricsOnce sync.Once
+
+// RegisterMetricAndTrackRateLimiterUsage sets the workqueue metrics provider, so the depth, add rate and
+// latency of workqueues created after this call become observable, and registers the kube client's rate
+// limiter latency, so API throttling becomes observable. name is only used for logging. The latter hooks into client-go's process-global metrics registration and is only
+// wired up once no matter how many times this is called.
+func RegisterMetricAndTrackRateLimiterUsage(name string) {
+	workqueue.SetProvider(prometheusMetricsProvider{})
+	registerClientGoMetricsOnce.Do(func() {
+		prometheus.MustRegister(kubeClientRateLimiterLatency)
+		clientmetrics.Register(clientmetrics.RegisterOpts{
+			RateLimiterLatency: kubeClientRateLimiterLatencyAdapter{},
+		})
+	})
+	glog.V(2).Infof("registered workqueue and kube client rate limiter metrics for %s", name)
+}
+
+// kubeClientRateLimiterLatencyAdapter adapts kubeClientRateLimiterLatency to client-go's
+// metrics.LatencyMetric interface.
+type kubeClientRateLimiterLatencyAdapter struct{}
+
+func (kubeClientRateLimiterLatencyAdapter) Observe(verb string, u url.URL, latency time.Duration) {
+	kubeClientRateLimiterLatency.WithLabelValues(verb).Observe(latency.Seconds())
+}
+
+// prometheusMetricsProvider implements workqueue.MetricsProvider, exposing queue depth, add rate and
+// work/wait latency as Prometheus collectors.
+type prometheusMetricsProvider struct{}
+
+func (prometheusMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "depth",
+		Help:        "Current depth of the workqueue",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(gauge)
+	return gauge
+}
+
+func (prometheusMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "adds_total",
+		Help:        "Total number of items added to the workqueue",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(counter)
+	return counter
+}
+
+func (prometheusMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "queue_latency_seconds",
+		Help:        "How long an item stays in the workqueue before being processed",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(histogram)
+	return histogram
+}
+
+func (prometheusMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "work_duration_seconds",
+		Help:        "How long processing an item from the workqueue takes",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(histogram)
+	return histogram
+}
+
+func (prometheusMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "retries_total",
+		Help:        "Total number of retries handled by the workqueue",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(counter)
+	return counter
+}
+
+func (prometheusMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "unfinished_work_seconds",
+		Help:        "How long in seconds the currently processed item has been processing",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(gauge)
+	return gauge
+}
+
+func (prometheusMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "workqueue",
+		Name:        "longest_running_processor_seconds",
+		Help:        "How long the longest running processor has been running",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	prometheus.MustRegister(gauge)
+	return gauge
+}
+
+// ServeMetrics starts an HTTP server exposing the registered Prometheus collectors at endpoint on port.
+func ServeMetrics(endpoint string, port string) {
+	http.Handle(endpoint, promhttp.Handler())
+	glog.Infof("serving Prometheus metrics on port %s at %s", port, endpoint)
+	go func() {
+		if err := http.ListenAndServe(":"+port, nil); err != nil {
+			glog.Errorf("failed to serve Prometheus metrics: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,452 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/robfig/cron"
+
+	apiv1 "k8s.io/api/core/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	crdclientset "k8s.io/spark-on-k8s-operator/pkg/client/clientset/versioned"
+	crdinformers "k8s.io/spark-on-k8s-operator/pkg/client/informers/externalversions"
+	"k8s.io/spark-on-k8s-operator/pkg/crd"
+)
+
+// scheduleTickResolution is how often the controller re-evaluates every known schedule.
+const scheduleTickResolution = 10 * time.Second
+
+// errSkipRun signals that a scheduled run was skipped because of the ConcurrencyForbid policy.
+var errSkipRun = fmt.Errorf("scheduled run skipped due to ConcurrencyForbid policy")
+
+// ScheduledSparkApplicationController manages instances of ScheduledSparkApplication and creates
+// SparkApplication objects on the configured cron schedule.
+type ScheduledSparkApplicationController struct {
+	crdClient        crdclientset.Interface
+	kubeClient       clientset.Interface
+	extensionsClient apiextensionsclient.Interface
+	queue            workqueue.RateLimitingInterface
+	informer         cache.SharedIndexInformer
+	store            cache.Store
+	recorder         record.EventRecorder
+	clock            clock.Clock
+}
+
+// NewScheduled creates a new ScheduledSparkApplicationController.
+func NewScheduled(
+	crdClient crdclientset.Interface,
+	kubeClient clientset.Interface,
+	extensionsClient apiextensionsclient.Interface) *ScheduledSparkApplicationController {
+	v1alpha1.AddToScheme(scheme.Scheme)
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(glog.V(2).Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: kubeClient.CoreV1().Events(apiv1.NamespaceAll),
+	})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: "spark-scheduled-operator"})
+
+	return newScheduledSparkApplicationController(crdClient, kubeClient, extensionsClient, recorder, clock.RealClock{})
+}
+
+func newScheduledSparkApplicationController(
+	crdClient crdclientset.Interface,
+	kubeClient clientset.Interface,
+	extensionsClient apiextensionsclient.Interface,
+	eventRecorder record.EventRecorder,
+	clock clock.Clock) *ScheduledSparkApplicationController {
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(),
+		"scheduled-spark-application-controller")
+
+	controller := &ScheduledSparkApplicationController{
+		crdClient:        crdClient,
+		kubeClient:       kubeClient,
+		extensionsClient: extensionsClient,
+		recorder:         eventRecorder,
+		queue:            queue,
+		clock:            clock,
+	}
+
+	informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0*time.Second)
+	controller.informer = informerFactory.Sparkoperator().V1alpha1().ScheduledSparkApplications().Informer()
+	controller.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    controller.onAdd,
+		UpdateFunc: controller.onUpdate,
+		DeleteFunc: controller.onDelete,
+	})
+	controller.store = controller.informer.GetStore()
+
+	return controller
+}
+
+// Start starts the ScheduledSparkApplicationController by registering a watcher for
+// ScheduledSparkApplication objects and a periodic ticker that evaluates schedules.
+func (s *ScheduledSparkApplicationController) Start(workers int, stopCh <-chan struct{}) error {
+	glog.Info("Starting the ScheduledSparkApplication controller")
+
+	glog.Infof("Creating CustomResourceDefinition %s", crd.ScheduledCRDFullName)
+	if err := crd.CreateScheduledCRD(s.extensionsClient); err != nil {
+		return fmt.Errorf("failed to create CustomResourceDefinition %s: %v", crd.ScheduledCRDFullName, err)
+	}
+
+	glog.Info("Starting the ScheduledSparkApplication informer")
+	go s.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, s.informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for cache to sync")
+	}
+
+	glog.Info("Starting the workers of the ScheduledSparkApplication controller")
+	for i := 0; i < workers; i++ {
+		go wait.Until(s.runWorker, time.Second, stopCh)
+	}
+
+	go wait.Until(s.enqueueAll, scheduleTickResolution, stopCh)
+
+	return nil
+}
+
+// Stop stops the ScheduledSparkApplicationController.
+func (s *ScheduledSparkApplicationController) Stop() {
+	glog.Info("Stopping the ScheduledSparkApplication controller")
+	s.queue.ShutDown()
+	glog.Infof("Deleting CustomResourceDefinition %s", crd.ScheduledCRDFullName)
+	if err := crd.DeleteScheduledCRD(s.extensionsClient); err != nil {
+		glog.Errorf("failed to delete CustomResourceDefinition %s: %v", crd.ScheduledCRDFullName, err)
+	}
+}
+
+func (s *ScheduledSparkApplicationController) onAdd(obj interface{}) {
+	app := obj.(*v1alpha1.ScheduledSparkApplication)
+	s.enqueue(app)
+}
+
+func (s *ScheduledSparkApplicationController) onUpdate(oldObj, newObj interface{}) {
+	s.enqueue(newObj.(*v1alpha1.ScheduledSparkApplication))
+}
+
+func (s *ScheduledSparkApplicationController) onDelete(obj interface{}) {
+	app, ok := obj.(*v1alpha1.ScheduledSparkApplication)
+	if !ok {
+		return
+	}
+	key := getApplicationKey(app.Namespace, app.Name)
+	s.queue.Forget(key)
+	s.queue.Done(key)
+}
+
+func (s *ScheduledSparkApplicationController) enqueue(app *v1alpha1.ScheduledSparkApplication) {
+	key := getApplicationKey(app.Namespace, app.Name)
+	s.queue.Add(key)
+}
+
+// enqueueAll re-enqueues every known ScheduledSparkApplication so its schedule gets re-evaluated.
+func (s *ScheduledSparkApplicationController) enqueueAll() {
+	for _, obj := range s.store.List() {
+		app := obj.(*v1alpha1.ScheduledSparkApplication)
+		s.enqueue(app)
+	}
+}
+
+func (s *ScheduledSparkApplicationController) runWorker() {
+	defer utilruntime.HandleCrash()
+	for s.processNextItem() {
+	}
+}
+
+func (s *ScheduledSparkApplicationController) processNextItem() bool {
+	key, quit := s.queue.Get()
+	if quit {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	err := s.syncScheduledSparkApplication(key.(string))
+	if err == nil {
+		s.queue.Forget(key)
+		return true
+	}
+
+	utilruntime.HandleError(fmt.Errorf("failed to sync ScheduledSparkApplication %q: %v", key, err))
+	s.queue.AddRateLimited(key)
+	return true
+}
+
+func (s *ScheduledSparkApplicationController) syncScheduledSparkApplication(key string) error {
+	app, err := s.getScheduledSparkApplicationFromStore(key)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if app.Spec.Suspend != nil && *app.Spec.Suspend {
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(app.Spec.Schedule)
+	if err != nil {
+		return s.markScheduleFailedValidation(app, fmt.Errorf("failed to parse schedule %q of "+
+			"ScheduledSparkApplication %s: %v", app.Spec.Schedule, app.Name, err))
+	}
+
+	if app.Status.ScheduleState == v1alpha1.FailedValidationState {
+		updated, err := s.clearScheduleFailedValidation(app)
+		if err != nil {
+			return err
+		}
+		app = updated
+	}
+
+	now := s.clock.Now()
+	// Use the ScheduledSparkApplication's creation time as the reference time for computing the next
+	// run until it has actually run at least once; otherwise LastRun is the zero value and the next
+	// run would incorrectly be computed from year 1, firing immediately regardless of the schedule.
+	reference := app.Status.LastRun.Time
+	if reference.IsZero() {
+		reference = app.CreationTimestamp.Time
+	}
+	next := schedule.Next(reference)
+	if next.After(now) {
+		return s.updateNextRun(app, next)
+	}
+
+	if err := s.handleConcurrencyPolicy(app); err != nil {
+		if err == errSkipRun {
+			return s.updateNextRun(app, schedule.Next(now))
+		}
+		return err
+	}
+
+	updated, err := s.startNextRun(app, now)
+	if err != nil {
+		return err
+	}
+
+	return s.pruneHistory(updated)
+}
+
+func (s *ScheduledSparkApplicationController) handleConcurrencyPolicy(
+	app *v1alpha1.ScheduledSparkApplication) error {
+	if app.Spec.ConcurrencyPolicy != v1alpha1.ConcurrencyForbid &&
+		app.Spec.ConcurrencyPolicy != v1alpha1.ConcurrencyReplace {
+		return nil
+	}
+
+	running, err := s.isLastRunRunning(app)
+	if err != nil || !running {
+		return err
+	}
+
+	if app.Spec.ConcurrencyPolicy == v1alpha1.ConcurrencyForbid {
+		glog.V(2).Infof("Last run %s of ScheduledSparkApplication %s is still running, skipping this run",
+			app.Status.LastRunName, app.Name)
+		return errSkipRun
+	}
+
+	glog.Infof("Killing last run %s of ScheduledSparkApplication %s to start a new one", app.Status.LastRunName,
+		app.Name)
+	return s.crdClient.SparkoperatorV1alpha1().SparkApplications(app.Namespace).Delete(app.Status.LastRunName,
+		&metav1.DeleteOptions{})
+}
+
+func (s *ScheduledSparkApplicationController) isLastRunRunning(app *v1alpha1.ScheduledSparkApplication) (bool,
+	error) {
+	if app.Status.LastRunName == "" {
+		return false, nil
+	}
+
+	last, err := s.crdClient.SparkoperatorV1alpha1().SparkApplications(app.Namespace).Get(app.Status.LastRunName,
+		metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return !isAppTerminated(last.Status.AppState.State), nil
+}
+
+func (s *ScheduledSparkApplicationController) startNextRun(app *v1alpha1.ScheduledSparkApplication,
+	now time.Time) (*v1alpha1.ScheduledSparkApplication, error) {
+	run := buildSparkApplicationFromTemplate(app, now)
+	created, err := s.crdClient.SparkoperatorV1alpha1().SparkApplications(app.Namespace).Create(run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SparkApplication for ScheduledSparkApplication %s: %v", app.Name, err)
+	}
+
+	toUpdate := app.DeepCopy()
+	toUpdate.Status.LastRun = metav1.NewTime(now)
+	toUpdate.Status.LastRunName = created.Name
+	toUpdate.Status.ScheduleState = v1alpha1.ScheduledState
+	return s.crdClient.SparkoperatorV1alpha1().ScheduledSparkApplications(app.Namespace).Update(toUpdate)
+}
+
+func (s *ScheduledSparkApplicationController) updateNextRun(app *v1alpha1.ScheduledSparkApplication,
+	next time.Time) error {
+	toUpdate := app.DeepCopy()
+	toUpdate.Status.NextRun = metav1.NewTime(next)
+	_, err := s.crdClient.SparkoperatorV1alpha1().ScheduledSparkApplications(app.Namespace).Update(toUpdate)
+	return err
+}
+
+// markScheduleFailedValidation records validationErr on app's status as FailedValidationState so a bad
+// cron schedule is observable instead of being silently retried forever, then returns validationErr so
+// the caller still reports and retries the sync.
+func (s *ScheduledSparkApplicationController) markScheduleFailedValidation(
+	app *v1alpha1.ScheduledSparkApplication, validationErr error) error {
+	toUpdate := app.DeepCopy()
+	toUpdate.Status.ScheduleState = v1alpha1.FailedValidationState
+	toUpdate.Status.Reason = validationErr.Error()
+	if _, err := s.crdClient.SparkoperatorV1alpha1().ScheduledSparkApplications(app.Namespace).Update(
+		toUpdate); err != nil {
+		return err
+	}
+	return validationErr
+}
+
+// clearScheduleFailedValidation clears a previously recorded FailedValidationState now that app's
+// schedule has parsed successfully, and returns the updated object.
+func (s *ScheduledSparkApplicationController) clearScheduleFailedValidation(
+	app *v1alpha1.ScheduledSparkApplication) (*v1alpha1.ScheduledSparkApplication, error) {
+	toUpdate := app.DeepCopy()
+	toUpdate.Status.ScheduleState = v1alpha1.ScheduledState
+	toUpdate.Status.Reason = ""
+	return s.crdClient.SparkoperatorV1alpha1().ScheduledSparkApplications(app.Namespace).Update(toUpdate)
+}
+
+// pruneHistory deletes old SparkApplication runs beyond the configured history limits.
+func (s *ScheduledSparkApplicationController) pruneHistory(app *v1alpha1.ScheduledSparkApplication) error {
+	runs, err := s.crdClient.SparkoperatorV1alpha1().SparkApplications(app.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("scheduledsparkapplication=%s", app.Name),
+	})
+	if err != nil {
+		return err
+	}
+
+	var successful, failed []v1alpha1.SparkApplication
+	for _, run := range runs.Items {
+		switch run.Status.AppState.State {
+		case v1alpha1.CompletedState:
+			successful = append(successful, run)
+		case v1alpha1.FailedState:
+			failed = append(failed, run)
+		}
+	}
+
+	sort.Slice(successful, func(i, j int) bool {
+		return successful[i].Status.CompletionTime.Before(&successful[j].Status.CompletionTime)
+	})
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Status.CompletionTime.Before(&failed[j].Status.CompletionTime)
+	})
+
+	successful, err = s.deleteExcess(app, successful, app.Spec.SuccessfulRunHistoryLimit)
+	if err != nil {
+		return err
+	}
+	failed, err = s.deleteExcess(app, failed, app.Spec.FailedRunHistoryLimit)
+	if err != nil {
+		return err
+	}
+
+	return s.updateRunHistory(app, successful, failed)
+}
+
+// deleteExcess deletes the oldest runs beyond limit and returns the runs that were retained.
+func (s *ScheduledSparkApplicationController) deleteExcess(app *v1alpha1.ScheduledSparkApplication,
+	runs []v1alpha1.SparkApplication, limit *int32) ([]v1alpha1.SparkApplication, error) {
+	if limit == nil || int32(len(runs)) <= *limit {
+		return runs, nil
+	}
+
+	for _, run := range runs[:int32(len(runs))-*limit] {
+		glog.V(2).Infof("Pruning old run %s of ScheduledSparkApplication %s", run.Name, app.Name)
+		if err := s.crdClient.SparkoperatorV1alpha1().SparkApplications(app.Namespace).Delete(run.Name,
+			&metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return runs[int32(len(runs))-*limit:], nil
+}
+
+// updateRunHistory records the names of the retained successful and failed runs on the
+// ScheduledSparkApplication's status.
+func (s *ScheduledSparkApplicationController) updateRunHistory(app *v1alpha1.ScheduledSparkApplication,
+	successful, failed []v1alpha1.SparkApplication) error {
+	toUpdate := app.DeepCopy()
+	toUpdate.Status.PastSuccessfulRunNames = runNames(successful)
+	toUpdate.Status.PastFailedRunNames = runNames(failed)
+	_, err := s.crdClient.SparkoperatorV1alpha1().ScheduledSparkApplications(app.Namespace).Update(toUpdate)
+	return err
+}
+
+// runNames returns the names of the given SparkApplication runs.
+func runNames(runs []v1alpha1.SparkApplication) []string {
+	names := make([]string, 0, len(runs))
+	for _, run := range runs {
+		names = append(names, run.Name)
+	}
+	return names
+}
+
+func (s *ScheduledSparkApplicationController) getScheduledSparkApplicationFromStore(key string) (
+	*v1alpha1.ScheduledSparkApplication, error) {
+	item, exists, err := s.store.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &errors.StatusError{ErrStatus: metav1.Status{Reason: metav1.StatusReasonNotFound}}
+	}
+	return item.(*v1alpha1.ScheduledSparkApplication), nil
+}
+
+// buildSparkApplicationFromTemplate instantiates a SparkApplication from the ScheduledSparkApplication's
+// template, owned by the ScheduledSparkApplication so it gets garbage collected with its parent.
+func buildSparkApplicationFromTemplate(app *v1alpha1.ScheduledSparkApplication,
+	now time.Time) *v1alpha1.SparkApplication {
+	return &v1alpha1.SparkApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", app.Name, now.Unix()),
+			Namespace: app.Namespace,
+			Labels:    map[string]string{"scheduledsparkapplication": app.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, v1alpha1.SchemeGroupVersion.WithKind("ScheduledSparkApplication")),
+			},
+		},
+		Spec: app.Spec.Template,
+	}
+}
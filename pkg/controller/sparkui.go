@@ -0,0 +1,181 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	"k8s.io/spark-on-k8s-operator/pkg/config"
+)
+
+const (
+	sparkUIPortName       = "spark-driver-ui-port"
+	defaultSparkWebUIPort = int32(4040)
+)
+
+// createSparkUIService creates a ClusterIP Service fronting the driver's Spark UI port, selected by
+// the application's app ID label, and records it on the application's status.
+func createSparkUIService(app *v1alpha1.SparkApplication, kubeClient clientset.Interface) error {
+	service := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-ui-svc", app.Name),
+			Namespace: app.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, v1alpha1.SchemeGroupVersion.WithKind("SparkApplication")),
+			},
+		},
+		Spec: apiv1.ServiceSpec{
+			Selector: map[string]string{
+				config.SparkRoleLabel:    config.SparkDriverRole,
+				config.SparkAppNameLabel: app.Status.AppID,
+			},
+			Ports: []apiv1.ServicePort{
+				{
+					Name:       sparkUIPortName,
+					Port:       defaultSparkWebUIPort,
+					TargetPort: intstr.FromInt(int(defaultSparkWebUIPort)),
+				},
+			},
+		},
+	}
+
+	created, err := kubeClient.CoreV1().Services(app.Namespace).Create(service)
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create UI service for SparkApplication %s: %v", app.Name, err)
+		}
+		created, err = kubeClient.CoreV1().Services(app.Namespace).Get(service.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	app.Status.DriverInfo.WebUIServiceName = created.Name
+	app.Status.DriverInfo.WebUIPort = defaultSparkWebUIPort
+	return nil
+}
+
+// ingressURLTemplateData is the data made available to the --ingress-url-format template.
+type ingressURLTemplateData struct {
+	AppName      string
+	AppNamespace string
+}
+
+// createSparkUIIngress creates an Ingress in front of the Spark UI Service, with its host/path
+// rendered from urlFormat, and records the resulting address on the application's status.
+func createSparkUIIngress(
+	app *v1alpha1.SparkApplication,
+	service *apiv1.Service,
+	urlFormat string,
+	ingressClassName string,
+	kubeClient clientset.Interface) error {
+	address, err := renderIngressURL(urlFormat, app)
+	if err != nil {
+		return fmt.Errorf("failed to render ingress URL for SparkApplication %s: %v", app.Name, err)
+	}
+
+	host, path := splitIngressURL(address)
+	pathType := networkingv1.PathTypeImplementationSpecific
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-ui-ingress", app.Name),
+			Namespace: app.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, v1alpha1.SchemeGroupVersion.WithKind("SparkApplication")),
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     path,
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: service.Name,
+											Port: networkingv1.ServiceBackendPort{
+												Name: sparkUIPortName,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if ingressClassName != "" {
+		ingress.Annotations = map[string]string{"kubernetes.io/ingress.class": ingressClassName}
+	}
+
+	created, err := kubeClient.NetworkingV1().Ingresses(app.Namespace).Create(ingress)
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create UI ingress for SparkApplication %s: %v", app.Name, err)
+		}
+		created, err = kubeClient.NetworkingV1().Ingresses(app.Namespace).Get(ingress.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	app.Status.DriverInfo.WebUIIngressName = created.Name
+	app.Status.DriverInfo.WebUIIngressAddress = address
+	return nil
+}
+
+func renderIngressURL(urlFormat string, app *v1alpha1.SparkApplication) (string, error) {
+	tmpl, err := template.New("ingress-url").Parse(urlFormat)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ingressURLTemplateData{AppName: app.Name, AppNamespace: app.Namespace}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// splitIngressURL splits a rendered ingress URL into a host and a path, e.g.
+// "spark.example.com/ns/app" becomes ("spark.example.com", "/ns/app") and "app.spark.example.com"
+// becomes ("app.spark.example.com", "/").
+func splitIngressURL(url string) (string, string) {
+	for i, r := range url {
+		if r == '/' {
+			return url[:i], url[i:]
+		}
+	}
+	return url, "/"
+}
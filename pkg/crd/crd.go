@@ -0,0 +1,79 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+)
+
+// FullName is the fully qualified name of the SparkApplication CustomResourceDefinition.
+const FullName = "sparkapplications." + v1alpha1.GroupName
+
+// ScheduledCRDFullName is the fully qualified name of the ScheduledSparkApplication CustomResourceDefinition.
+const ScheduledCRDFullName = "scheduledsparkapplications." + v1alpha1.GroupName
+
+// CreateCRD creates the SparkApplication CustomResourceDefinition if it doesn't already exist.
+func CreateCRD(clientset apiextensionsclient.Interface) error {
+	return createCRD(clientset, FullName, "SparkApplication", "sparkapplications", "sparkapplication", "sa")
+}
+
+// DeleteCRD deletes the SparkApplication CustomResourceDefinition.
+func DeleteCRD(clientset apiextensionsclient.Interface) error {
+	return clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(FullName, nil)
+}
+
+// CreateScheduledCRD creates the ScheduledSparkApplication CustomResourceDefinition if it doesn't already exist.
+func CreateScheduledCRD(clientset apiextensionsclient.Interface) error {
+	return createCRD(clientset, ScheduledCRDFullName, "ScheduledSparkApplication", "scheduledsparkapplications",
+		"scheduledsparkapplication", "scheduledsa")
+}
+
+// DeleteScheduledCRD deletes the ScheduledSparkApplication CustomResourceDefinition.
+func DeleteScheduledCRD(clientset apiextensionsclient.Interface) error {
+	return clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(ScheduledCRDFullName, nil)
+}
+
+func createCRD(
+	clientset apiextensionsclient.Interface,
+	fullName, kind, plural, singular string,
+	shortNames ...string) error {
+	crd := &apiextensionsv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: fullName},
+		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+			Group:   v1alpha1.GroupName,
+			Version: v1alpha1.SchemeGroupVersion.Version,
+			Scope:   apiextensionsv1beta1.NamespaceScoped,
+			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+				Plural:     plural,
+				Singular:   singular,
+				Kind:       kind,
+				ShortNames: shortNames,
+			},
+		},
+	}
+
+	_, err := clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
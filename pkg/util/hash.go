@@ -0,0 +1,28 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"hash"
+	"hash/fnv"
+)
+
+// NewHash32 returns a new 32-bit FNV-1a hash.Hash32 used for computing short, stable
+// identifiers such as the application ID suffix.
+func NewHash32() hash.Hash32 {
+	return fnv.New32a()
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	"k8s.io/spark-on-k8s-operator/pkg/config"
+)
+
+// patchOperation is a single operation of a JSON patch, as used by the admission webhook response.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// patchPodSpec builds the list of JSON patch operations that apply the driver or executor pod
+// customizations carried by the SparkApplication spec but not expressible through spark-submit.
+func patchPodSpec(pod *apiv1.Pod, role string, app *v1alpha1.SparkApplication) []patchOperation {
+	var spec v1alpha1.SparkPodSpec
+	if role == config.SparkDriverRole {
+		spec = app.Spec.Driver.SparkPodSpec
+	} else {
+		spec = app.Spec.Executor.SparkPodSpec
+	}
+
+	var ops []patchOperation
+	ops = append(ops, addOrAppend("/spec/volumes", len(pod.Spec.Volumes), toInterfaceSlice(spec.Volumes))...)
+	if len(pod.Spec.Containers) > 0 {
+		ops = append(ops, addOrAppend("/spec/containers/0/volumeMounts", len(pod.Spec.Containers[0].VolumeMounts),
+			toInterfaceSlice(spec.VolumeMounts))...)
+		ops = append(ops, addOrAppend("/spec/containers/0/envFrom", len(pod.Spec.Containers[0].EnvFrom),
+			toInterfaceSlice(spec.EnvFrom))...)
+	}
+	ops = append(ops, addOrAppend("/spec/tolerations", len(pod.Spec.Tolerations), toInterfaceSlice(spec.Tolerations))...)
+	ops = append(ops, addOrAppend("/spec/containers", len(pod.Spec.Containers), toInterfaceSlice(spec.Sidecars))...)
+	ops = append(ops, addOrAppend("/spec/initContainers", len(pod.Spec.InitContainers),
+		toInterfaceSlice(spec.InitContainers))...)
+	ops = append(ops, addOrAppend("/spec/imagePullSecrets", len(pod.Spec.ImagePullSecrets),
+		toInterfaceSlice(imagePullSecretRefs(spec.ImagePullSecrets)))...)
+
+	if len(spec.NodeSelector) > 0 {
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/nodeSelector", Value: spec.NodeSelector})
+	}
+	if spec.Affinity != nil {
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/affinity", Value: spec.Affinity})
+	}
+	if spec.HostNetwork != nil {
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/hostNetwork", Value: *spec.HostNetwork})
+	}
+
+	return ops
+}
+
+// addOrAppend returns patch operations that set the field at path to values if the corresponding pod
+// field is currently empty, or append each value to it individually otherwise, since the JSON Patch
+// "-" append operator only accepts a single element per operation.
+func addOrAppend(path string, existingLen int, values []interface{}) []patchOperation {
+	if len(values) == 0 {
+		return nil
+	}
+	if existingLen == 0 {
+		return []patchOperation{{Op: "add", Path: path, Value: values}}
+	}
+	ops := make([]patchOperation, len(values))
+	for i, v := range values {
+		ops[i] = patchOperation{Op: "add", Path: path + "/-", Value: v}
+	}
+	return ops
+}
+
+func imagePullSecretRefs(secretNames []string) []apiv1.LocalObjectReference {
+	if len(secretNames) == 0 {
+		return nil
+	}
+	refs := make([]apiv1.LocalObjectReference, len(secretNames))
+	for i, name := range secretNames {
+		refs[i] = apiv1.LocalObjectReference{Name: name}
+	}
+	return refs
+}
+
+func toInterfaceSlice(slice interface{}) []interface{} {
+	switch v := slice.(type) {
+	case []apiv1.Volume:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []apiv1.VolumeMount:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []apiv1.EnvFromSource:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []apiv1.Toleration:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []apiv1.Container:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []apiv1.LocalObjectReference:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	default:
+		return nil
+	}
+}
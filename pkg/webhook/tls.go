@@ -0,0 +1,174 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	admissionregv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const certSecretName = "spark-webhook-certs"
+
+// certs holds a self-signed CA bundle and the TLS key pair the webhook server serves.
+type certs struct {
+	caCert []byte
+	cert   []byte
+	key    []byte
+}
+
+// bootstrapCerts loads the webhook's TLS certificates from a Secret, generating and persisting a new
+// self-signed CA and server certificate if one doesn't already exist.
+func bootstrapCerts(kubeClient clientset.Interface, namespace, serviceName string) (*certs, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(certSecretName, metav1.GetOptions{})
+	if err == nil {
+		return &certs{
+			caCert: secret.Data["ca.crt"],
+			cert:   secret.Data["tls.crt"],
+			key:    secret.Data["tls.key"],
+		}, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	generated, err := generateCerts(namespace, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	secret = &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: certSecretName, Namespace: namespace},
+		Data: map[string][]byte{
+			"ca.crt":  generated.caCert,
+			"tls.crt": generated.cert,
+			"tls.key": generated.key,
+		},
+	}
+	if _, err := kubeClient.CoreV1().Secrets(namespace).Create(secret); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	return generated, nil
+}
+
+func generateCerts(namespace, serviceName string) (*certs, error) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spark-operator-webhook-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsName := fmt.Sprintf("%s.%s.svc", serviceName, namespace)
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{dnsName},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, caTemplate, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certs{
+		caCert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		cert:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER}),
+		key:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(serverKey)}),
+	}, nil
+}
+
+// selfRegister creates or updates the MutatingWebhookConfiguration that routes every pod CREATE request
+// in all namespaces to this server, which only patches driver and executor pods.
+func (ws *WebhookServer) selfRegister() error {
+	failurePolicy := admissionregv1beta1.Ignore
+	config := &admissionregv1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: webhookConfigName},
+		Webhooks: []admissionregv1beta1.Webhook{
+			{
+				Name: webhookName,
+				ClientConfig: admissionregv1beta1.WebhookClientConfig{
+					Service: &admissionregv1beta1.ServiceReference{
+						Namespace: ws.namespace,
+						Name:      ws.serviceName,
+						Path:      strPtr(mutatePath),
+					},
+					CABundle: ws.certs.caCert,
+				},
+				Rules: []admissionregv1beta1.RuleWithOperations{
+					{
+						Operations: []admissionregv1beta1.OperationType{admissionregv1beta1.Create},
+						Rule: admissionregv1beta1.Rule{
+							APIGroups:   []string{""},
+							APIVersions: []string{"v1"},
+							Resources:   []string{"pods"},
+						},
+					},
+				},
+				FailurePolicy: &failurePolicy,
+			},
+		},
+	}
+
+	_, err := ws.kubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Create(config)
+	if errors.IsAlreadyExists(err) {
+		existing, getErr := ws.kubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(
+			webhookConfigName, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		config.ObjectMeta.ResourceVersion = existing.ObjectMeta.ResourceVersion
+		_, err = ws.kubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Update(config)
+	}
+	return err
+}
+
+func strPtr(s string) *string {
+	return &s
+}
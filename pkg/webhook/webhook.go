@@ -0,0 +1,211 @@
+/*
+Copyright 2017 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements a Kubernetes mutating admission webhook that patches driver and
+// executor pods with fields from the owning SparkApplication that spark-submit itself cannot set.
+package webhook
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/glog"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"k8s.io/spark-on-k8s-operator/pkg/apis/sparkoperator.k8s.io/v1alpha1"
+	"k8s.io/spark-on-k8s-operator/pkg/config"
+
+	crdclientset "k8s.io/spark-on-k8s-operator/pkg/client/clientset/versioned"
+)
+
+const (
+	webhookName          = "webhook.sparkoperator.k8s.io"
+	mutatePath           = "/webhook"
+	webhookConfigName    = "spark-webhook-config"
+	webhookServiceName   = "spark-webhook"
+	webhookContainerPort = 8080
+)
+
+// WebhookServer runs the mutating admission webhook that customizes driver and executor pods.
+type WebhookServer struct {
+	crdClient   crdclientset.Interface
+	kubeClient  clientset.Interface
+	server      *http.Server
+	certs       *certs
+	namespace   string
+	serviceName string
+}
+
+// New creates a new WebhookServer.
+func New(crdClient crdclientset.Interface, kubeClient clientset.Interface, namespace string) *WebhookServer {
+	return &WebhookServer{
+		crdClient:   crdClient,
+		kubeClient:  kubeClient,
+		namespace:   namespace,
+		serviceName: webhookServiceName,
+	}
+}
+
+// Start bootstraps TLS serving certificates, registers the MutatingWebhookConfiguration with the
+// API server and starts serving AdmissionReview requests.
+func (ws *WebhookServer) Start(stopCh <-chan struct{}) error {
+	certs, err := bootstrapCerts(ws.kubeClient, ws.namespace, ws.serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap TLS certificates for the webhook: %v", err)
+	}
+	ws.certs = certs
+
+	if err := ws.selfRegister(); err != nil {
+		return fmt.Errorf("failed to register the MutatingWebhookConfiguration %s: %v", webhookConfigName, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(mutatePath, ws.serve)
+
+	cert, err := tls.X509KeyPair(certs.cert, certs.key)
+	if err != nil {
+		return fmt.Errorf("failed to load the webhook's TLS key pair: %v", err)
+	}
+
+	ws.server = &http.Server{
+		Addr:      fmt.Sprintf(":%d", webhookContainerPort),
+		Handler:   mux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	go func() {
+		glog.Infof("starting the mutating admission webhook server on port %d", webhookContainerPort)
+		if err := ws.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("webhook server stopped serving: %v", err)
+		}
+	}()
+
+	go func() {
+		<-stopCh
+		ws.Stop()
+	}()
+
+	return nil
+}
+
+// Stop unregisters the MutatingWebhookConfiguration and stops serving.
+func (ws *WebhookServer) Stop() {
+	glog.Info("stopping the mutating admission webhook server")
+	if err := ws.kubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Delete(webhookConfigName,
+		&metav1.DeleteOptions{}); err != nil {
+		glog.Errorf("failed to delete MutatingWebhookConfiguration %s: %v", webhookConfigName, err)
+	}
+	if ws.server != nil {
+		ws.server.Close()
+	}
+}
+
+func (ws *WebhookServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := ws.admit(review.Request)
+	review.Response = response
+	review.Request = nil
+
+	encoded, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(encoded)
+}
+
+func (ws *WebhookServer) admit(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	response := &admissionv1beta1.AdmissionResponse{Allowed: true, UID: request.UID}
+
+	pod := &apiv1.Pod{}
+	if err := json.Unmarshal(request.Object.Raw, pod); err != nil {
+		return admissionError(request.UID, err)
+	}
+
+	role := pod.Labels[config.SparkRoleLabel]
+	if role != config.SparkDriverRole && role != config.SparkExecutorRole {
+		return response
+	}
+
+	appID := pod.Labels[config.SparkAppNameLabel]
+	if appID == "" {
+		return response
+	}
+
+	app, err := ws.getSparkApplication(pod.Namespace, appID)
+	if err != nil {
+		glog.Errorf("failed to find the SparkApplication owning pod %s in namespace %s: %v", pod.Name,
+			pod.Namespace, err)
+		return response
+	}
+
+	patchOps := patchPodSpec(pod, role, app)
+	if len(patchOps) == 0 {
+		return response
+	}
+
+	patch, err := json.Marshal(patchOps)
+	if err != nil {
+		return admissionError(request.UID, err)
+	}
+
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	response.Patch = patch
+	response.PatchType = &patchType
+	return response
+}
+
+func (ws *WebhookServer) getSparkApplication(namespace, appID string) (*v1alpha1.SparkApplication, error) {
+	apps, err := ws.crdClient.SparkoperatorV1alpha1().SparkApplications(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps.Items {
+		if apps.Items[i].Status.AppID == appID {
+			return &apps.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no SparkApplication with app ID %s found in namespace %s", appID, namespace)
+}
+
+func admissionError(uid types.UID, err error) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		UID:     uid,
+		Allowed: false,
+		Result:  &metav1.Status{Message: err.Error()},
+	}
+}